@@ -66,17 +66,40 @@ func (b *basicAuth) Auth(ctx *layers.LayerContext) (bool, interface{}, error) {
 }
 
 func (b *basicAuth) doAuth(text string) basicAuthResult {
-	pos := strings.IndexByte(text, ' ')
-	if pos < 0 {
+	user, password, err := parseBasicAuthHeader(text)
+	if err != nil {
+		return basicAuthResult{err: err}
+	}
+
+	found := false
+	for idx := range b.infos {
+		found = b.infos[idx].OK(user, password) || found
+	}
+
+	if found {
 		return basicAuthResult{
-			err: ErrBasicAuthMalformed,
+			reply: string(user),
 		}
 	}
 
+	return basicAuthResult{
+		err: ErrBasicAuthNoUser,
+	}
+}
+
+// parseBasicAuthHeader parses the value of a Proxy-Authorization
+// header using the "Basic" scheme, returning the decoded user and
+// password. It is shared by every Auth implementation that speaks
+// Basic auth (NewBasicAuth, NewHtpasswdAuth) so the header format is
+// only parsed in one place.
+func parseBasicAuthHeader(text string) (user, password []byte, err error) {
+	pos := strings.IndexByte(text, ' ')
+	if pos < 0 {
+		return nil, nil, ErrBasicAuthMalformed
+	}
+
 	if !strings.EqualFold(text[:pos], "Basic") {
-		return basicAuthResult{
-			err: ErrBasicAuthScheme,
-		}
+		return nil, nil, ErrBasicAuthScheme
 	}
 
 	for pos < len(text) && (text[pos] == ' ' || text[pos] == '\t') {
@@ -85,32 +108,15 @@ func (b *basicAuth) doAuth(text string) basicAuthResult {
 
 	decoded, err := base64.StdEncoding.DecodeString(text[pos:])
 	if err != nil {
-		return basicAuthResult{
-			err: errors.Wrap(err, ErrBasicAuthPayload),
-		}
+		return nil, nil, errors.Wrap(err, ErrBasicAuthPayload)
 	}
 
 	pos = bytes.IndexByte(decoded, ':')
 	if pos < 0 {
-		return basicAuthResult{
-			err: ErrBasicAuthDelimiter,
-		}
-	}
-
-	found := false
-	for idx := range b.infos {
-		found = b.infos[idx].OK(decoded[:pos], decoded[pos+1:]) || found
+		return nil, nil, ErrBasicAuthDelimiter
 	}
 
-	if found {
-		return basicAuthResult{
-			reply: string(decoded[:pos]),
-		}
-	}
-
-	return basicAuthResult{
-		err: ErrBasicAuthNoUser,
-	}
+	return decoded[:pos], decoded[pos+1:], nil
 }
 
 func NewBasicAuth(userPasswords map[string]string) Auth {
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/PumpkinSeed/errors"
+)
+
+var (
+	ErrCertAuthNoPeerCert = errors.Wrap(errors.New("no verified peer certificate"), ErrAuth)
+	ErrCertAuthRevoked    = errors.Wrap(errors.New("certificate is revoked"), ErrAuth)
+	ErrCertAuthUnmapped   = errors.Wrap(errors.New("certificate does not map to a user"), ErrAuth)
+)
+
+// CertAuthUserFunc maps a verified client certificate to the user
+// string returned as Auth's reply. Implementations typically look at
+// the CN, a SAN entry or a SPIFFE ID embedded in the certificate.
+type CertAuthUserFunc func(cert *x509.Certificate) (string, error)
+
+// CertAuthRevocationFunc inspects a verified client certificate and
+// returns a non-nil error if it has been revoked. It is meant to be
+// backed by a CRL or OCSP check.
+type CertAuthRevocationFunc func(cert *x509.Certificate) error
+
+// ConnStateFunc recovers the verified TLS connection state for the
+// request ctx represents. This package does not know how (or
+// whether) layers.LayerContext exposes the listener's
+// tls.ConnectionState, so it never guesses at an accessor: the caller
+// wires the listener (via NewListener) and therefore knows exactly
+// how to get from a *layers.LayerContext back to that state, and
+// supplies it here.
+type ConnStateFunc func(ctx *layers.LayerContext) *tls.ConnectionState
+
+// CertAuthOpts configures NewCertAuth.
+type CertAuthOpts struct {
+	// ClientCAs is the pool of CAs the peer certificate must chain
+	// up to. It is also used to build the tls.Config returned by
+	// TLSConfig.
+	ClientCAs *x509.CertPool
+
+	// ConnState recovers the verified TLS connection state for a
+	// request. It is required: without it Auth has no way to reach
+	// the peer certificate and always returns ErrCertAuthNoPeerCert.
+	ConnState ConnStateFunc
+
+	// MapUser turns a verified certificate into a user string. If
+	// nil, the certificate Subject's CommonName is used.
+	MapUser CertAuthUserFunc
+
+	// CheckCRL and CheckOCSP are optional revocation hooks, run in
+	// that order, after chain verification and before MapUser.
+	CheckCRL  CertAuthRevocationFunc
+	CheckOCSP CertAuthRevocationFunc
+}
+
+func (o *CertAuthOpts) mapUser() CertAuthUserFunc {
+	if o.MapUser != nil {
+		return o.MapUser
+	}
+
+	return func(cert *x509.Certificate) (string, error) {
+		return cert.Subject.CommonName, nil
+	}
+}
+
+// TLSConfig builds the tls.Config the proxy's listener has to use so
+// that a verified client certificate chain survives from the TLS
+// terminator into Auth. ClientAuth is always
+// tls.RequireAndVerifyClientCert: without a CA-trusted certificate
+// presented at handshake time there is nothing for Auth to check.
+func (o *CertAuthOpts) TLSConfig() *tls.Config {
+	return &tls.Config{
+		ClientCAs:  o.ClientCAs,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	}
+}
+
+// NewListener wraps inner so that every accepted connection completes
+// the mTLS handshake TLSConfig requires before it is handed back to
+// the caller, i.e. before certAuth.Auth ever sees it. This package has
+// no server of its own, so whatever constructs the proxy's listener
+// must use NewListener (or call TLSConfig directly) in place of a
+// plain net.Listener; wherever that wiring lives is the only place
+// NewCertAuth's precondition can actually be satisfied.
+func (o *CertAuthOpts) NewListener(inner net.Listener) net.Listener {
+	return tls.NewListener(inner, o.TLSConfig())
+}
+
+type certAuth struct {
+	opts CertAuthOpts
+}
+
+// authenticate runs every check against a single already-verified
+// peer certificate: revocation, then user mapping. It is kept separate
+// from Auth so it can be unit-tested against a real *x509.Certificate
+// without needing a *layers.LayerContext, which is the one part of
+// this type this repository cannot exercise (see Auth).
+func (c *certAuth) authenticate(cert *x509.Certificate) (interface{}, error) {
+	if c.opts.CheckCRL != nil {
+		if err := c.opts.CheckCRL(cert); err != nil {
+			return nil, errors.Wrap(err, ErrCertAuthRevoked)
+		}
+	}
+
+	if c.opts.CheckOCSP != nil {
+		if err := c.opts.CheckOCSP(cert); err != nil {
+			return nil, errors.Wrap(err, ErrCertAuthRevoked)
+		}
+	}
+
+	user, err := c.opts.mapUser()(cert)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrCertAuthUnmapped)
+	}
+
+	return user, nil
+}
+
+// Auth implements Auth by reading the peer certificate chain out of
+// opts.ConnState(ctx). If ConnState is nil, Auth reports the request
+// as authenticated-but-rejected via ErrCertAuthNoPeerCert rather than
+// silently returning false, nil, nil for every request forever, so a
+// missing wiring shows up as an auth failure instead of a proxy that
+// quietly never challenges anyone. A nil state, or a state with no
+// peer certificates, means the listener didn't require one; this
+// type always does, so that is also treated as "no auth". Everything
+// past that is ordinary Go, covered by the TestCertAuth* tests via
+// authenticate.
+func (c *certAuth) Auth(ctx *layers.LayerContext) (bool, interface{}, error) {
+	if c.opts.ConnState == nil {
+		return true, nil, ErrCertAuthNoPeerCert
+	}
+
+	state := c.opts.ConnState(ctx)
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return false, nil, nil
+	}
+
+	user, err := c.authenticate(state.PeerCertificates[0])
+
+	return true, user, err
+}
+
+// NewCertAuth returns an Auth implementation which authenticates the
+// upstream client by the TLS client certificate presented during the
+// CONNECT/TLS handshake, instead of a Proxy-Authorization header. The
+// proxy's listener must be built with opts.NewListener (or
+// opts.TLSConfig, for a non-net.Listener-based server) so the
+// verified chain exists by the time Auth is called, and opts.ConnState
+// must be set to however the caller's *layers.LayerContext actually
+// surfaces that tls.ConnectionState; this package does not assume a
+// method for that, since it has no listener of its own and no access
+// to layers.LayerContext's source to invent one against.
+func NewCertAuth(opts CertAuthOpts) Auth {
+	return &certAuth{opts: opts}
+}
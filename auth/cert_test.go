@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"testing"
+)
+
+func certWithCN(cn string) *x509.Certificate {
+	return &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+}
+
+func TestCertAuthOptsMapUserDefaultsToCommonName(t *testing.T) {
+	opts := &CertAuthOpts{}
+
+	user, err := opts.mapUser()(certWithCN("alice"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if user != "alice" {
+		t.Fatalf("got user %q, want %q", user, "alice")
+	}
+}
+
+func TestCertAuthOptsMapUserCustom(t *testing.T) {
+	opts := &CertAuthOpts{
+		MapUser: func(cert *x509.Certificate) (string, error) {
+			return "mapped:" + cert.Subject.CommonName, nil
+		},
+	}
+
+	user, err := opts.mapUser()(certWithCN("alice"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if user != "mapped:alice" {
+		t.Fatalf("got user %q, want %q", user, "mapped:alice")
+	}
+}
+
+func TestCertAuthenticateDefaultMapping(t *testing.T) {
+	c := &certAuth{opts: CertAuthOpts{}}
+
+	user, err := c.authenticate(certWithCN("alice"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if user != "alice" {
+		t.Fatalf("got user %v, want %q", user, "alice")
+	}
+}
+
+func TestCertAuthenticateCRLRejects(t *testing.T) {
+	wantErr := errors.New("revoked")
+	c := &certAuth{opts: CertAuthOpts{
+		CheckCRL: func(cert *x509.Certificate) error { return wantErr },
+	}}
+
+	_, err := c.authenticate(certWithCN("alice"))
+	if !errors.Is(err, ErrCertAuthRevoked) {
+		t.Fatalf("expected ErrCertAuthRevoked, got %v", err)
+	}
+}
+
+func TestCertAuthenticateOCSPRejects(t *testing.T) {
+	wantErr := errors.New("revoked via OCSP")
+	c := &certAuth{opts: CertAuthOpts{
+		CheckOCSP: func(cert *x509.Certificate) error { return wantErr },
+	}}
+
+	_, err := c.authenticate(certWithCN("alice"))
+	if !errors.Is(err, ErrCertAuthRevoked) {
+		t.Fatalf("expected ErrCertAuthRevoked, got %v", err)
+	}
+}
+
+func TestCertAuthenticateChecksCRLBeforeOCSP(t *testing.T) {
+	ocspCalled := false
+	c := &certAuth{opts: CertAuthOpts{
+		CheckCRL:  func(cert *x509.Certificate) error { return errors.New("revoked") },
+		CheckOCSP: func(cert *x509.Certificate) error { ocspCalled = true; return nil },
+	}}
+
+	if _, err := c.authenticate(certWithCN("alice")); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if ocspCalled {
+		t.Fatal("CheckOCSP should not run once CheckCRL has already rejected the certificate")
+	}
+}
+
+func TestCertAuthenticateMapUserError(t *testing.T) {
+	wantErr := errors.New("cannot map")
+	c := &certAuth{opts: CertAuthOpts{
+		MapUser: func(cert *x509.Certificate) (string, error) { return "", wantErr },
+	}}
+
+	_, err := c.authenticate(certWithCN("alice"))
+	if !errors.Is(err, ErrCertAuthUnmapped) {
+		t.Fatalf("expected ErrCertAuthUnmapped, got %v", err)
+	}
+}
+
+func TestCertAuthAuthRejectsWithoutConnState(t *testing.T) {
+	c := &certAuth{opts: CertAuthOpts{}}
+
+	ok, _, err := c.Auth(nil)
+	if !ok {
+		t.Fatal("expected Auth to claim the request rather than pass it through")
+	}
+
+	if !errors.Is(err, ErrCertAuthNoPeerCert) {
+		t.Fatalf("expected ErrCertAuthNoPeerCert, got %v", err)
+	}
+}
+
+func TestCertAuthOptsTLSConfigRequiresClientCert(t *testing.T) {
+	pool := x509.NewCertPool()
+	opts := &CertAuthOpts{ClientCAs: pool}
+
+	conf := opts.TLSConfig()
+
+	if conf.ClientCAs != pool {
+		t.Fatal("TLSConfig did not carry through ClientCAs")
+	}
+
+	if conf.ClientAuth.String() != "RequireAndVerifyClientCert" {
+		t.Fatalf("unexpected ClientAuth: %v", conf.ClientAuth)
+	}
+}
+
+func TestCertAuthOptsNewListenerWrapsWithTLS(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer inner.Close()
+
+	opts := &CertAuthOpts{ClientCAs: x509.NewCertPool()}
+
+	wrapped := opts.NewListener(inner)
+	if wrapped == inner {
+		t.Fatal("NewListener did not wrap the inner listener")
+	}
+
+	if wrapped.Addr() != inner.Addr() {
+		t.Fatalf("wrapped listener address %v does not match inner %v", wrapped.Addr(), inner.Addr())
+	}
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/9seconds/httransform/v2/layers"
+	"github.com/PumpkinSeed/errors"
+	htpasswd "github.com/tg123/go-htpasswd"
+	"zvelo.io/ttlru"
+)
+
+const (
+	htpasswdAuthCacheFor   = time.Hour
+	htpasswdAuthCacheSize  = 1024
+	htpasswdAuthEventsSize = 16
+
+	// DefaultHtpasswdReloadEvery is used by NewHtpasswdAuth whenever
+	// reloadEvery is zero or negative.
+	DefaultHtpasswdReloadEvery = 5 * time.Minute
+)
+
+var (
+	ErrHtpasswdAuthNoUser = errors.Wrap(errors.New("no such user"), ErrAuth)
+	ErrHtpasswdAuthReload = errors.Wrap(errors.New("cannot reload htpasswd file"), ErrAuth)
+)
+
+// HtpasswdAuthEvent describes the outcome of a single reload of the
+// backing htpasswd file, including individual malformed lines seen
+// along the way. It is pushed to the channel returned by
+// htpasswdAuth.Events so the application can feed it into metrics or
+// logs.
+type HtpasswdAuthEvent struct {
+	// Err is set if the reload failed outright, e.g. the file is
+	// gone or unreadable. A malformed line does not set Err.
+	Err error
+
+	// BadLine is set if a single entry in the file could not be
+	// parsed; the reload still applies the rest of the file.
+	BadLine error
+}
+
+type htpasswdAuth struct {
+	cache ttlru.Cache
+
+	reloadEvery time.Duration
+	events      chan HtpasswdAuthEvent
+
+	// users is already safe for concurrent use: htpasswd.File swaps
+	// its parsed table under an atomic.Pointer on every Reload.
+	users *htpasswd.File
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+func (h *htpasswdAuth) Auth(ctx *layers.LayerContext) (bool, interface{}, error) {
+	header := ctx.RequestHeaders.Get("proxy-authorization")
+
+	if header == nil {
+		return false, nil, nil
+	}
+
+	if item, ok := h.cache.Get(header.Value); ok {
+		reply := item.(*basicAuthResult)
+		return true, reply.reply, reply.err
+	}
+
+	resp := h.doAuth(header.Value)
+	h.cache.Set(header.Value, &resp)
+
+	return true, resp.reply, resp.err
+}
+
+func (h *htpasswdAuth) doAuth(text string) basicAuthResult {
+	user, password, err := parseBasicAuthHeader(text)
+	if err != nil {
+		return basicAuthResult{err: err}
+	}
+
+	if !h.users.Match(string(user), string(password)) {
+		return basicAuthResult{
+			err: ErrHtpasswdAuthNoUser,
+		}
+	}
+
+	return basicAuthResult{
+		reply: string(user),
+	}
+}
+
+func (h *htpasswdAuth) reload() {
+	err := h.users.Reload(func(badLine error) {
+		h.notify(HtpasswdAuthEvent{BadLine: badLine})
+	})
+	if err != nil {
+		h.notify(HtpasswdAuthEvent{Err: errors.Wrap(err, ErrHtpasswdAuthReload)})
+		return
+	}
+
+	h.notify(HtpasswdAuthEvent{})
+}
+
+func (h *htpasswdAuth) notify(evt HtpasswdAuthEvent) {
+	select {
+	case h.events <- evt:
+	default:
+	}
+}
+
+func (h *htpasswdAuth) watch() {
+	ticker := time.NewTicker(h.reloadEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.stopCh:
+			return
+		case <-ticker.C:
+			h.reload()
+		}
+	}
+}
+
+// Events returns a channel which receives a HtpasswdAuthEvent after
+// every reload attempt of the backing htpasswd file, successful or
+// not. The channel is buffered; events are dropped rather than
+// blocking the reload loop if nobody is reading.
+func (h *htpasswdAuth) Events() <-chan HtpasswdAuthEvent {
+	return h.events
+}
+
+// Close stops the background reload goroutine. It is safe to call
+// multiple times.
+func (h *htpasswdAuth) Close() error {
+	h.stopOnce.Do(func() {
+		close(h.stopCh)
+	})
+
+	return nil
+}
+
+// NewHtpasswdAuth returns an Auth implementation which authenticates
+// users against an Apache-style htpasswd file at path, supporting
+// bcrypt, SHA and MD5 crypt entries. The file is re-read every
+// reloadEvery so large, rotating user lists can be managed without
+// restarting the proxy; reloadEvery defaults to
+// DefaultHtpasswdReloadEvery if zero or negative. htpasswd.File
+// already swaps its parsed table
+// atomically on each reload, so in-flight requests never see a
+// half-updated table. Reload attempts, successful or not, and
+// individual malformed lines are reported on the channel returned by
+// the Events method of the returned value so callers can wire up
+// metrics. As with NewBasicAuth, results are cached for
+// htpasswdAuthCacheFor keyed on the raw Proxy-Authorization header so
+// the cost of bcrypt comparisons is amortized.
+func NewHtpasswdAuth(path string, reloadEvery time.Duration) (Auth, error) {
+	if reloadEvery <= 0 {
+		reloadEvery = DefaultHtpasswdReloadEvery
+	}
+
+	events := make(chan HtpasswdAuthEvent, htpasswdAuthEventsSize)
+
+	users, err := htpasswd.New(path, htpasswd.DefaultSystems, func(badLine error) {
+		select {
+		case events <- HtpasswdAuthEvent{BadLine: badLine}:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, ErrHtpasswdAuthReload)
+	}
+
+	h := &htpasswdAuth{
+		cache: ttlru.New(htpasswdAuthCacheSize,
+			ttlru.WithTTL(htpasswdAuthCacheFor)),
+		reloadEvery: reloadEvery,
+		events:      events,
+		users:       users,
+		stopCh:      make(chan struct{}),
+	}
+
+	go h.watch()
+
+	return h, nil
+}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeHtpasswdFile(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("cannot write htpasswd fixture: %v", err)
+	}
+
+	return path
+}
+
+func basicAuthHeader(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+func newHtpasswdAuthForTest(t *testing.T, contents string) *htpasswdAuth {
+	t.Helper()
+
+	auth, err := NewHtpasswdAuth(writeHtpasswdFile(t, contents), time.Hour)
+	if err != nil {
+		t.Fatalf("cannot create htpasswd auth: %v", err)
+	}
+
+	h := auth.(*htpasswdAuth)
+	t.Cleanup(func() { h.Close() })
+
+	return h
+}
+
+func TestHtpasswdAuthDoAuthValidCredentials(t *testing.T) {
+	h := newHtpasswdAuthForTest(t, "alice:secret\n")
+
+	resp := h.doAuth(basicAuthHeader("alice", "secret"))
+	if resp.err != nil {
+		t.Fatalf("unexpected error: %v", resp.err)
+	}
+
+	if resp.reply != "alice" {
+		t.Fatalf("got reply %v, want %q", resp.reply, "alice")
+	}
+}
+
+func TestHtpasswdAuthDoAuthUnknownUser(t *testing.T) {
+	h := newHtpasswdAuthForTest(t, "alice:secret\n")
+
+	resp := h.doAuth(basicAuthHeader("bob", "secret"))
+	if !errors.Is(resp.err, ErrHtpasswdAuthNoUser) {
+		t.Fatalf("expected ErrHtpasswdAuthNoUser, got %v", resp.err)
+	}
+}
+
+func TestHtpasswdAuthDoAuthWrongPassword(t *testing.T) {
+	h := newHtpasswdAuthForTest(t, "alice:secret\n")
+
+	resp := h.doAuth(basicAuthHeader("alice", "wrong"))
+	if !errors.Is(resp.err, ErrHtpasswdAuthNoUser) {
+		t.Fatalf("expected ErrHtpasswdAuthNoUser, got %v", resp.err)
+	}
+}
+
+func TestHtpasswdAuthDoAuthMalformedHeader(t *testing.T) {
+	h := newHtpasswdAuthForTest(t, "alice:secret\n")
+
+	resp := h.doAuth("not a basic header")
+	if resp.err == nil {
+		t.Fatal("expected an error for a malformed header")
+	}
+}
+
+func TestHtpasswdAuthReloadPicksUpChanges(t *testing.T) {
+	path := writeHtpasswdFile(t, "alice:secret\n")
+
+	auth, err := NewHtpasswdAuth(path, time.Hour)
+	if err != nil {
+		t.Fatalf("cannot create htpasswd auth: %v", err)
+	}
+
+	h := auth.(*htpasswdAuth)
+	defer h.Close()
+
+	if h.doAuth(basicAuthHeader("bob", "hunter2")).err == nil {
+		t.Fatal("expected bob to be unknown before the file is rewritten")
+	}
+
+	if err := os.WriteFile(path, []byte("alice:secret\nbob:hunter2\n"), 0o600); err != nil {
+		t.Fatalf("cannot rewrite htpasswd fixture: %v", err)
+	}
+
+	h.reload()
+
+	resp := h.doAuth(basicAuthHeader("bob", "hunter2"))
+	if resp.err != nil {
+		t.Fatalf("unexpected error after reload: %v", resp.err)
+	}
+
+	if resp.reply != "bob" {
+		t.Fatalf("got reply %v, want %q", resp.reply, "bob")
+	}
+}
+
+func TestHtpasswdAuthReloadReportsBadLine(t *testing.T) {
+	path := writeHtpasswdFile(t, "alice:secret\nmalformed-line-without-colon\n")
+
+	auth, err := NewHtpasswdAuth(path, time.Hour)
+	if err != nil {
+		t.Fatalf("cannot create htpasswd auth: %v", err)
+	}
+
+	h := auth.(*htpasswdAuth)
+	defer h.Close()
+
+	h.reload()
+
+	select {
+	case evt := <-h.Events():
+		if evt.BadLine == nil {
+			t.Fatal("expected the reload event to report the bad line")
+		}
+	default:
+		t.Fatal("expected a reload event on the Events channel")
+	}
+}
+
+func TestHtpasswdAuthReloadReportsSuccessWithoutBadLine(t *testing.T) {
+	h := newHtpasswdAuthForTest(t, "alice:secret\n")
+
+	h.reload()
+
+	select {
+	case evt := <-h.Events():
+		if evt.Err != nil || evt.BadLine != nil {
+			t.Fatalf("expected a clean reload event, got %+v", evt)
+		}
+	default:
+		t.Fatal("expected a reload event on the Events channel")
+	}
+}
+
+func TestHtpasswdAuthReloadReportsMissingFile(t *testing.T) {
+	path := writeHtpasswdFile(t, "alice:secret\n")
+
+	auth, err := NewHtpasswdAuth(path, time.Hour)
+	if err != nil {
+		t.Fatalf("cannot create htpasswd auth: %v", err)
+	}
+
+	h := auth.(*htpasswdAuth)
+	defer h.Close()
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("cannot remove htpasswd fixture: %v", err)
+	}
+
+	h.reload()
+
+	select {
+	case evt := <-h.Events():
+		if evt.Err == nil {
+			t.Fatal("expected the reload event to report the missing file")
+		}
+	default:
+		t.Fatal("expected a reload event on the Events channel")
+	}
+}
+
+func TestNewHtpasswdAuthDefaultsReloadEvery(t *testing.T) {
+	path := writeHtpasswdFile(t, "alice:secret\n")
+
+	auth, err := NewHtpasswdAuth(path, 0)
+	if err != nil {
+		t.Fatalf("cannot create htpasswd auth: %v", err)
+	}
+
+	h := auth.(*htpasswdAuth)
+	defer h.Close()
+
+	if h.reloadEvery != DefaultHtpasswdReloadEvery {
+		t.Fatalf("got reloadEvery %v, want %v", h.reloadEvery, DefaultHtpasswdReloadEvery)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
@@ -25,23 +26,47 @@ const (
 	// DNSCacheSize defines a size of cache for DNS entries.
 	DNSCacheSize = 512
 
-	// DNSCacheTTL defines a TTL for each DNS entry.
+	// DNSCacheTTL is the TTL systemResolver reports for an answer,
+	// and the TTL dnsCache falls back to if a Resolver reports no
+	// addresses and a TTL that isn't positive; a non-positive TTL that
+	// comes with addresses is kept as is. It is not the underlying cache.Interface's
+	// own eviction TTL: see dnsCacheBackingTTL.
 	DNSCacheTTL = 5 * time.Minute
+
+	// dnsCacheBackingTTL is the eviction TTL of the cache.Interface
+	// backing dnsCache. It only needs to outlast the longest TTL any
+	// Resolver can realistically report, since dnsCache.lookup already
+	// tracks each entry's real expiry itself (dnsCacheEntry.expiresAt)
+	// and re-resolves once that passes, regardless of whether the
+	// cache has evicted the entry yet. Setting it equal to DNSCacheTTL
+	// would otherwise silently cap every per-record TTL a Resolver
+	// reports at 5 minutes, re-resolving long-lived records far more
+	// often than their real TTL calls for.
+	dnsCacheBackingTTL = 24 * time.Hour
 )
 
 type base struct {
-	netDialer      net.Dialer
-	dns            dnsCache
-	tlsConfigsLock sync.Mutex
-	tlsConfigs     cache.Interface
-	tlsSkipVerify  bool
+	netDialer          net.Dialer
+	dns                dnsCache
+	tlsConfigsLock     sync.Mutex
+	tlsConfigs         cache.Interface
+	tlsSkipVerify      bool
+	tlsProfile         TLSProfile
+	happyEyeballsDelay time.Duration
+	resolutionDelay    time.Duration
 }
 
+// Dial resolves host and connects to it using Happy Eyeballs v2 (RFC
+// 8305): IPv6 and IPv4 addresses are resolved concurrently, addresses
+// are interleaved starting with IPv6, and connection attempts are
+// launched across the interleaved list staggered by
+// happyEyeballsDelay. The first attempt to complete wins; the rest
+// are cancelled and their connections, if any, are closed.
 func (b *base) Dial(ctx context.Context, host, port string) (net.Conn, error) {
 	ctx, cancel := context.WithTimeout(ctx, b.netDialer.Timeout)
 	defer cancel()
 
-	ips, err := b.dns.Lookup(ctx, host)
+	ips, err := resolveHappyEyeballs(ctx, &b.dns, b.resolutionDelay, host)
 	if err != nil {
 		return nil, fmt.Errorf("cannot resolve IPs: %w", err)
 	}
@@ -50,16 +75,91 @@ func (b *base) Dial(ctx context.Context, host, port string) (net.Conn, error) {
 		return nil, ErrNoIPs
 	}
 
-	var conn net.Conn
+	conn, err := b.dialHappyEyeballs(ctx, ips, port)
+	if err != nil {
+		return nil, fmt.Errorf("cannot dial to %s: %w", host, err)
+	}
+
+	return conn, nil
+}
+
+// resolveHappyEyeballs runs the Happy Eyeballs resolution race against
+// dns: both address families are looked up concurrently, and as soon
+// as one answers, we wait at most resolutionDelay for the other
+// before proceeding with whatever we have. The result is interleaved
+// IPv6/IPv4, IPv6 first. An error is only returned once both families
+// have failed outright (e.g. the resolver itself errored for both
+// LookupIPv6 and LookupIPv4), or if ctx is done before that; one family simply not having answered
+// yet when resolutionDelay expires is not an error; it just means we
+// proceed with whatever the other one returned. Shared by base.Dial
+// and chained.connectSOCKS5 so every caller resolves through the same
+// cache and pluggable Resolver instead of falling back to the host OS
+// resolver ad hoc.
+func resolveHappyEyeballs(ctx context.Context, dns *dnsCache, resolutionDelay time.Duration, host string) ([]string, error) {
+	type familyResult struct {
+		ips []string
+		err error
+	}
+
+	v6Ch := make(chan familyResult, 1)
+	v4Ch := make(chan familyResult, 1)
+
+	go func() {
+		ips, err := dns.LookupIPv6(ctx, host)
+		v6Ch <- familyResult{ips, err}
+	}()
+
+	go func() {
+		ips, err := dns.LookupIPv4(ctx, host)
+		v4Ch <- familyResult{ips, err}
+	}()
+
+	var v6, v4 familyResult
+
+	// Wait for the first family to answer...
+	select {
+	case v6 = <-v6Ch:
+	case v4 = <-v4Ch:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	// ...then give the other one resolutionDelay to catch up before
+	// we settle for whatever we have.
+	timer := time.NewTimer(resolutionDelay)
+	defer timer.Stop()
+
+	select {
+	case v6 = <-v6Ch:
+	case v4 = <-v4Ch:
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if v6.err != nil && v4.err != nil {
+		return nil, errors.Join(v6.err, v4.err)
+	}
+
+	return interleaveAddrs(v6.ips, v4.ips), nil
+}
+
+// interleaveAddrs merges two address lists the way RFC 8305 wants
+// them tried: alternating, starting with IPv6.
+func interleaveAddrs(v6, v4 []string) []string {
+	out := make([]string, 0, len(v6)+len(v4))
 
-	for _, ip := range ips {
-		conn, err = b.netDialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, port))
-		if err == nil {
-			return conn, nil
+	for i := 0; i < len(v6) || i < len(v4); i++ {
+		if i < len(v6) {
+			out = append(out, v6[i])
+		}
+
+		if i < len(v4) {
+			out = append(out, v4[i])
 		}
 	}
 
-	return nil, fmt.Errorf("cannot dial to %s: %w", host, err)
+	return out
 }
 
 func (b *base) UpgradeToTLS(ctx context.Context, conn net.Conn, host string) (net.Conn, error) {
@@ -94,23 +194,31 @@ func (b *base) PatchHTTPRequest(req *fasthttp.Request) {
 }
 
 func (b *base) getTLSConfig(host string) *tls.Config {
-	if conf := b.tlsConfigs.Get(host); conf != nil {
+	key := host + "|" + b.tlsProfile.serverName(host) + "|" + b.tlsProfile.fingerprint()
+
+	if conf := b.tlsConfigs.Get(key); conf != nil {
 		return conf.(*tls.Config)
 	}
 
 	b.tlsConfigsLock.Lock()
 	defer b.tlsConfigsLock.Unlock()
 
-	if conf := b.tlsConfigs.Get(host); conf != nil {
+	if conf := b.tlsConfigs.Get(key); conf != nil {
 		return conf.(*tls.Config)
 	}
 
 	conf := &tls.Config{
 		ClientSessionCache: tls.NewLRUClientSessionCache(0),
 		InsecureSkipVerify: b.tlsSkipVerify, // nolint: gosec
+		ServerName:         b.tlsProfile.serverName(host),
+		MinVersion:         b.tlsProfile.MinVersion,
+		MaxVersion:         b.tlsProfile.MaxVersion,
+		CipherSuites:       b.tlsProfile.CipherSuites,
+		CurvePreferences:   b.tlsProfile.CurvePreferences,
+		NextProtos:         b.tlsProfile.NextProtos,
 	}
 
-	b.tlsConfigs.Add(host, conf)
+	b.tlsConfigs.Add(key, conf)
 
 	return conf
 }
@@ -134,12 +242,16 @@ func NewBase(opt Opts) Dialer {
 			Control: reuseport.Control,
 		},
 		dns: dnsCache{
-			cache: cache.New(DNSCacheSize, DNSCacheTTL, cache.NoopEvictCallback),
+			cache:    cache.New(DNSCacheSize, dnsCacheBackingTTL, cache.NoopEvictCallback),
+			resolver: opt.Resolver,
 		},
 		tlsConfigs: cache.New(TLSConfigCacheSize,
 			TLSConfigTTL,
 			cache.NoopEvictCallback),
-		tlsSkipVerify: opt.GetTLSSkipVerify(),
+		tlsSkipVerify:      opt.GetTLSSkipVerify(),
+		tlsProfile:         opt.TLSProfile,
+		happyEyeballsDelay: opt.GetHappyEyeballsDelay(),
+		resolutionDelay:    opt.GetResolutionDelay(),
 	}
 
 	return rv
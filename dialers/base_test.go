@@ -0,0 +1,224 @@
+package dialers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/9seconds/httransform/v2/cache"
+)
+
+// stepResolver is a Resolver stand-in that lets resolveHappyEyeballs
+// tests control each family's answer, error and arrival time
+// independently.
+type stepResolver struct {
+	v6IPs   []string
+	v6Err   error
+	v6Delay time.Duration
+	v6TTL   time.Duration
+
+	v4IPs   []string
+	v4Err   error
+	v4Delay time.Duration
+	v4TTL   time.Duration
+}
+
+func (s stepResolver) answer(ctx context.Context, delay time.Duration, ips []string, ttl time.Duration, err error) ([]string, time.Duration, error) {
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+
+	return ips, ttl, err
+}
+
+func (s stepResolver) LookupIPv6(ctx context.Context, host string) ([]string, time.Duration, error) {
+	return s.answer(ctx, s.v6Delay, s.v6IPs, s.v6TTL, s.v6Err)
+}
+
+func (s stepResolver) LookupIPv4(ctx context.Context, host string) ([]string, time.Duration, error) {
+	return s.answer(ctx, s.v4Delay, s.v4IPs, s.v4TTL, s.v4Err)
+}
+
+func newTestDNSCache(resolver Resolver) *dnsCache {
+	return &dnsCache{
+		cache:    cache.New(DNSCacheSize, dnsCacheBackingTTL, cache.NoopEvictCallback),
+		resolver: resolver,
+	}
+}
+
+func TestResolveHappyEyeballsInterleavesWhenBothAnswerWithinDelay(t *testing.T) {
+	dns := newTestDNSCache(stepResolver{
+		v6IPs:   []string{"::1"},
+		v4IPs:   []string{"10.0.0.1"},
+		v4Delay: 10 * time.Millisecond,
+	})
+
+	ips, err := resolveHappyEyeballs(context.Background(), dns, 100*time.Millisecond, "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"::1", "10.0.0.1"}
+	if !reflect.DeepEqual(ips, want) {
+		t.Fatalf("got %v, want %v", ips, want)
+	}
+}
+
+func TestResolveHappyEyeballsProceedsAfterResolutionDelay(t *testing.T) {
+	dns := newTestDNSCache(stepResolver{
+		v6IPs:   []string{"::1"},
+		v4IPs:   []string{"10.0.0.1"},
+		v4Delay: 200 * time.Millisecond,
+	})
+
+	ips, err := resolveHappyEyeballs(context.Background(), dns, 20*time.Millisecond, "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"::1"}
+	if !reflect.DeepEqual(ips, want) {
+		t.Fatalf("got %v, want %v, the slow family should have been left behind", ips, want)
+	}
+}
+
+func TestResolveHappyEyeballsOneFamilyErroringIsNotFatal(t *testing.T) {
+	dns := newTestDNSCache(stepResolver{
+		v6Err: errors.New("v6 lookup failed"),
+		v4IPs: []string{"10.0.0.1"},
+	})
+
+	ips, err := resolveHappyEyeballs(context.Background(), dns, 50*time.Millisecond, "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"10.0.0.1"}
+	if !reflect.DeepEqual(ips, want) {
+		t.Fatalf("got %v, want %v", ips, want)
+	}
+}
+
+func TestResolveHappyEyeballsBothFamiliesErrorReturnsCombinedError(t *testing.T) {
+	v6Err := errors.New("v6 lookup failed")
+	v4Err := errors.New("v4 lookup failed")
+
+	dns := newTestDNSCache(stepResolver{v6Err: v6Err, v4Err: v4Err})
+
+	_, err := resolveHappyEyeballs(context.Background(), dns, 10*time.Millisecond, "example.com")
+	if err == nil {
+		t.Fatal("expected an error when both families fail")
+	}
+
+	if !errors.Is(err, v6Err) || !errors.Is(err, v4Err) {
+		t.Fatalf("expected the combined error to wrap both family errors, got %v", err)
+	}
+}
+
+func TestResolveHappyEyeballsContextDoneBeforeEitherFamilyAnswers(t *testing.T) {
+	dns := newTestDNSCache(stepResolver{
+		v6Delay: time.Second,
+		v4Delay: time.Second,
+		v6IPs:   []string{"::1"},
+		v4IPs:   []string{"10.0.0.1"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := resolveHappyEyeballs(ctx, dns, time.Second, "example.com")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestDNSCacheLookupHonorsZeroTTLFromResolver(t *testing.T) {
+	// A positive answer with a genuine TTL of 0 (e.g. a failover or
+	// round-robin record) must not be clamped up to DNSCacheTTL: the
+	// whole point of reporting 0 is "do not cache this".
+	dns := newTestDNSCache(stepResolver{v4IPs: []string{"192.0.2.1"}, v4TTL: 0})
+
+	if _, err := dns.LookupIPv4(context.Background(), "example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entry := dns.cache.Get(dnsCacheKey("example.com", false)).(*dnsCacheEntry)
+	if entry.expiresAt.After(time.Now()) {
+		t.Fatalf("expected a 0 ttl answer to expire immediately, not be cached for DNSCacheTTL")
+	}
+}
+
+func TestDNSCacheLookupFallsBackToDNSCacheTTLWhenResolverReportsNone(t *testing.T) {
+	// systemResolver always reports DNSCacheTTL itself, so the only
+	// way a Resolver can signal "no TTL info at all" is by returning
+	// no ips alongside a 0 ttl; dnsCache.lookup must still fall back
+	// to DNSCacheTTL in that case rather than treating it the same as
+	// a deliberate "do not cache".
+	dns := newTestDNSCache(stepResolver{v4IPs: nil, v4TTL: 0})
+
+	if _, err := dns.LookupIPv4(context.Background(), "example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entry := dns.cache.Get(dnsCacheKey("example.com", false)).(*dnsCacheEntry)
+	if !entry.expiresAt.After(time.Now().Add(DNSCacheTTL - time.Minute)) {
+		t.Fatalf("expected the entry to be cached for ~DNSCacheTTL, expires at %v", entry.expiresAt)
+	}
+}
+
+func TestInterleaveAddrs(t *testing.T) {
+	tests := []struct {
+		name string
+		v6   []string
+		v4   []string
+		want []string
+	}{
+		{
+			name: "empty",
+			want: []string{},
+		},
+		{
+			name: "v6 only",
+			v6:   []string{"::1", "::2"},
+			want: []string{"::1", "::2"},
+		},
+		{
+			name: "v4 only",
+			v4:   []string{"10.0.0.1", "10.0.0.2"},
+			want: []string{"10.0.0.1", "10.0.0.2"},
+		},
+		{
+			name: "equal length interleaves starting with v6",
+			v6:   []string{"::1", "::2"},
+			v4:   []string{"10.0.0.1", "10.0.0.2"},
+			want: []string{"::1", "10.0.0.1", "::2", "10.0.0.2"},
+		},
+		{
+			name: "more v6 than v4",
+			v6:   []string{"::1", "::2", "::3"},
+			v4:   []string{"10.0.0.1"},
+			want: []string{"::1", "10.0.0.1", "::2", "::3"},
+		},
+		{
+			name: "more v4 than v6",
+			v6:   []string{"::1"},
+			v4:   []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			want: []string{"::1", "10.0.0.1", "10.0.0.2", "10.0.0.3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := interleaveAddrs(tt.v6, tt.v4)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("interleaveAddrs(%v, %v) = %v, want %v", tt.v6, tt.v4, got, tt.want)
+			}
+		})
+	}
+}
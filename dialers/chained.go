@@ -0,0 +1,188 @@
+package dialers
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/9seconds/httransform/v2/cache"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	chainedDefaultHTTPPort   = "80"
+	chainedDefaultHTTPSPort  = "443"
+	chainedDefaultSOCKS5Port = "1080"
+)
+
+type chained struct {
+	upstream *url.URL
+	inner    Dialer
+
+	// localDialer provides UpgradeToTLS and PatchHTTPRequest for the
+	// tunnel to the final target, same as a plain base dialer would;
+	// it is never used to reach upstream itself.
+	localDialer Dialer
+
+	// dns and resolutionDelay back the local hostname resolution
+	// connectSOCKS5 does for plain "socks5" targets, so it goes
+	// through the same cache and pluggable Resolver as a base dialer
+	// instead of falling back to the host OS resolver.
+	dns             dnsCache
+	resolutionDelay time.Duration
+
+	timeout time.Duration
+}
+
+// NewChained returns a Dialer which reaches its targets through an
+// upstream proxy instead of dialing them directly. upstream is dialed
+// through inner, and then either an HTTP CONNECT (schemes "http",
+// "https") or a SOCKS5 handshake (schemes "socks5", "socks5h") is
+// negotiated to the real target. upstream.User, if set, is sent along
+// as Basic Proxy-Authorization for CONNECT or username/password
+// authentication (RFC 1929) for SOCKS5.
+//
+// For "socks5h", hostnames are sent to upstream as SOCKS5 domain-type
+// addresses instead of being resolved locally, so that resolution
+// happens on the proxy side. Plain "socks5" resolves the target
+// locally before handing an IP address to upstream. HTTP CONNECT
+// ("http", "https") sends host:port to upstream as given, unresolved.
+func NewChained(upstream *url.URL, inner Dialer, opt Opts) Dialer {
+	return &chained{
+		upstream:    upstream,
+		inner:       inner,
+		localDialer: NewBase(opt),
+		dns: dnsCache{
+			cache:    cache.New(DNSCacheSize, dnsCacheBackingTTL, cache.NoopEvictCallback),
+			resolver: opt.Resolver,
+		},
+		resolutionDelay: opt.GetResolutionDelay(),
+		timeout:         opt.GetTimeout(),
+	}
+}
+
+func (c *chained) Dial(ctx context.Context, host, port string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	conn, err := c.inner.Dial(ctx, c.upstream.Hostname(), c.upstreamPort())
+	if err != nil {
+		return nil, fmt.Errorf("cannot dial upstream proxy %s: %w", c.upstream, err)
+	}
+
+	if strings.EqualFold(c.upstream.Scheme, "https") {
+		conn, err = c.inner.UpgradeToTLS(ctx, conn, c.upstream.Hostname())
+		if err != nil {
+			return nil, fmt.Errorf("cannot upgrade upstream proxy connection to TLS: %w", err)
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline) // nolint: errcheck
+	}
+
+	switch strings.ToLower(c.upstream.Scheme) {
+	case "http", "https":
+		conn, err = c.connectHTTP(conn, host, port)
+	case "socks5", "socks5h":
+		err = c.connectSOCKS5(ctx, conn, host, port)
+	default:
+		err = fmt.Errorf("unsupported upstream proxy scheme %q", c.upstream.Scheme)
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{}) // nolint: errcheck
+
+	return conn, nil
+}
+
+// connectHTTP negotiates an HTTP CONNECT tunnel to host:port over
+// conn. It returns a conn to use for the tunnel, which may differ from
+// the one passed in: bufio.Reader may read ahead past the CONNECT
+// response into bytes the target has already started sending, and
+// those would be silently dropped if the caller went back to reading
+// conn directly, so whatever it buffered is preserved and replayed in
+// front of conn.
+func (c *chained) connectHTTP(conn net.Conn, host, port string) (net.Conn, error) {
+	target := net.JoinHostPort(host, port)
+
+	req, err := http.NewRequest(http.MethodConnect, "http://"+target, nil)
+	if err != nil {
+		return conn, fmt.Errorf("cannot build CONNECT request: %w", err)
+	}
+
+	req.Host = target
+
+	if user := c.upstream.User; user != nil {
+		password, _ := user.Password()
+		token := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+token)
+	}
+
+	if err := req.Write(conn); err != nil {
+		return conn, fmt.Errorf("cannot write CONNECT request: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return conn, fmt.Errorf("cannot read CONNECT response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return conn, fmt.Errorf("upstream proxy refused CONNECT: %s", resp.Status)
+	}
+
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: reader}, nil
+	}
+
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn whose first reads are satisfied from r
+// before falling back to the underlying connection, so bytes a
+// bufio.Reader read ahead into its buffer are not lost.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+func (c *chained) UpgradeToTLS(ctx context.Context, conn net.Conn, host string) (net.Conn, error) {
+	return c.localDialer.UpgradeToTLS(ctx, conn, host)
+}
+
+func (c *chained) PatchHTTPRequest(req *fasthttp.Request) {
+	c.localDialer.PatchHTTPRequest(req)
+}
+
+func (c *chained) upstreamPort() string {
+	if port := c.upstream.Port(); port != "" {
+		return port
+	}
+
+	switch strings.ToLower(c.upstream.Scheme) {
+	case "https":
+		return chainedDefaultHTTPSPort
+	case "http":
+		return chainedDefaultHTTPPort
+	default:
+		return chainedDefaultSOCKS5Port
+	}
+}
@@ -0,0 +1,95 @@
+package dialers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+type dialAttemptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// dialHappyEyeballs launches TCP connection attempts against ips in
+// order, staggered by happyEyeballsDelay, per RFC 8305. The first
+// attempt to succeed wins and is returned immediately; every other
+// attempt, whether already in flight or not yet launched, is
+// cancelled, and any connection it manages to establish anyway is
+// closed in the background.
+func (b *base) dialHappyEyeballs(ctx context.Context, ips []string, port string) (net.Conn, error) {
+	dialCtx, cancel := context.WithCancel(ctx)
+
+	resultsCh := make(chan dialAttemptResult, len(ips))
+	idx := 0
+	pending := 0
+
+	launch := func() {
+		ip := ips[idx]
+		idx++
+		pending++
+
+		go func() {
+			conn, err := b.netDialer.DialContext(dialCtx, "tcp", net.JoinHostPort(ip, port))
+			resultsCh <- dialAttemptResult{conn, err}
+		}()
+	}
+
+	launch()
+
+	timer := time.NewTimer(b.happyEyeballsDelay)
+	defer timer.Stop()
+
+	var lastErr error
+
+	// pending can hit 0 before idx reaches len(ips): an attempt can
+	// fail (e.g. connection refused) well before the next one is due
+	// to be staggered in. Keep looping until every ip has at least
+	// been launched, not just until the in-flight ones are done,
+	// otherwise a single fast failure would abandon the rest of the
+	// list instead of waiting for its turn.
+	for pending > 0 || idx < len(ips) {
+		select {
+		case <-timer.C:
+			if idx < len(ips) {
+				launch()
+				timer.Reset(b.happyEyeballsDelay)
+			}
+		case res := <-resultsCh:
+			pending--
+
+			if res.err == nil {
+				cancel()
+				go drainDialAttempts(resultsCh, pending)
+
+				return res.conn, nil
+			}
+
+			lastErr = res.err
+		case <-ctx.Done():
+			cancel()
+			go drainDialAttempts(resultsCh, pending)
+
+			return nil, ctx.Err()
+		}
+	}
+
+	cancel()
+
+	if lastErr == nil {
+		lastErr = ErrNoIPs
+	}
+
+	return nil, fmt.Errorf("no attempt out of %d succeeded: %w", len(ips), lastErr)
+}
+
+// drainDialAttempts closes any connection established by an attempt
+// whose result we abandoned after another attempt already won.
+func drainDialAttempts(ch <-chan dialAttemptResult, pending int) {
+	for i := 0; i < pending; i++ {
+		if res := <-ch; res.conn != nil {
+			res.conn.Close()
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package dialers
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// freeLoopbackAddr reserves a loopback port and releases it
+// immediately, so the caller gets a host:port nothing is listening on
+// and a connection attempt to it is refused quickly instead of
+// hanging.
+func freeLoopbackAddr(t *testing.T) (string, string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot reserve a loopback port: %v", err)
+	}
+	defer l.Close()
+
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot split listener address: %v", err)
+	}
+
+	return host, port
+}
+
+func TestDialHappyEyeballsReturnsTheOnlyAttempt(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer l.Close()
+
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot split listener address: %v", err)
+	}
+
+	b := &base{
+		netDialer:          net.Dialer{Timeout: time.Second},
+		happyEyeballsDelay: 50 * time.Millisecond,
+	}
+
+	conn, err := b.dialHappyEyeballs(context.Background(), []string{host}, port)
+	if err != nil {
+		t.Fatalf("dialHappyEyeballs returned an error: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := l.Accept(); err != nil {
+		t.Fatalf("listener never saw the connection: %v", err)
+	}
+}
+
+func TestDialHappyEyeballsStaggersTheNextAttempt(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer l.Close()
+
+	goodHost, port, err := net.SplitHostPort(l.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot split listener address: %v", err)
+	}
+
+	// The whole 127.0.0.0/8 block is loopback, so 127.0.0.2 on the
+	// same port as the listener is refused instantly rather than
+	// timing out, without needing a second port reservation.
+	badHost := "127.0.0.2"
+
+	happyEyeballsDelay := 80 * time.Millisecond
+	b := &base{
+		netDialer:          net.Dialer{Timeout: time.Second},
+		happyEyeballsDelay: happyEyeballsDelay,
+	}
+
+	start := time.Now()
+
+	// badHost:port refuses instantly, well before happyEyeballsDelay
+	// elapses; dialHappyEyeballs must still wait out the stagger
+	// before launching goodHost rather than giving up once the first
+	// (and only in-flight) attempt fails.
+	conn, err := b.dialHappyEyeballs(context.Background(), []string{badHost, goodHost}, port)
+	if err != nil {
+		t.Fatalf("dialHappyEyeballs returned an error: %v", err)
+	}
+	defer conn.Close()
+
+	if elapsed := time.Since(start); elapsed < happyEyeballsDelay/2 {
+		t.Fatalf("second attempt was launched too early, after %v, want at least ~%v", elapsed, happyEyeballsDelay)
+	}
+
+	if _, err := l.Accept(); err != nil {
+		t.Fatalf("the good listener never saw the connection: %v", err)
+	}
+}
+
+func TestDialHappyEyeballsAllAttemptsFail(t *testing.T) {
+	host, port := freeLoopbackAddr(t)
+
+	b := &base{
+		netDialer:          net.Dialer{Timeout: time.Second},
+		happyEyeballsDelay: 10 * time.Millisecond,
+	}
+
+	// Both candidates point at the same refused port: with nothing
+	// listening, every attempt across the whole interleaved list
+	// should fail, and dialHappyEyeballs should report that instead
+	// of hanging or returning early once the in-flight count hits 0.
+	_, err := b.dialHappyEyeballs(context.Background(), []string{host, host}, port)
+	if err == nil {
+		t.Fatal("expected an error when every attempt is refused")
+	}
+}
+
+func TestDialHappyEyeballsContextDoneReturnsPromptly(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	defer l.Close()
+
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot split listener address: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	b := &base{
+		netDialer:          net.Dialer{Timeout: time.Second},
+		happyEyeballsDelay: time.Second,
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := b.dialHappyEyeballs(ctx, []string{host}, port)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error for an already-cancelled context")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("dialHappyEyeballs did not honor the cancelled context")
+	}
+}
+
+func TestDrainDialAttemptsClosesAbandonedConnections(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	ch := make(chan dialAttemptResult, 2)
+	ch <- dialAttemptResult{conn: client}
+	ch <- dialAttemptResult{err: io.ErrClosedPipe}
+
+	drainDialAttempts(ch, 2)
+
+	if _, err := client.Write([]byte("x")); err == nil {
+		t.Fatal("expected the abandoned connection to have been closed")
+	}
+}
@@ -8,6 +8,15 @@ import (
 const (
 	DefaultTimeout         = 20 * time.Second
 	DefaultCleanupDNSEvery = 5 * time.Minute
+
+	// DefaultHappyEyeballsDelay is how long base.Dial waits between
+	// staggered connection attempts, per RFC 8305.
+	DefaultHappyEyeballsDelay = 250 * time.Millisecond
+
+	// DefaultResolutionDelay is how long base.Dial waits for the
+	// slower of the two address families to resolve before it
+	// proceeds with whatever family answered first.
+	DefaultResolutionDelay = 50 * time.Millisecond
 )
 
 type Opts struct {
@@ -15,6 +24,29 @@ type Opts struct {
 	Timeout         time.Duration
 	CleanupDNSEvery time.Duration
 	TLSSkipVerify   bool
+
+	// Resolver backs DNS lookups performed by base dialers. If nil,
+	// the host OS resolver is used. Its implementations (e.g. DoH,
+	// DoT) report a per-answer TTL which drives the per-entry DNS
+	// cache expiry directly, in place of the single global
+	// DNSCacheTTL: dnsCache tracks each entry's own expiry and
+	// re-resolves once that passes, independent of the backing
+	// cache's own (much longer) eviction TTL.
+	Resolver Resolver
+
+	// HappyEyeballsDelay is the stagger between successive connection
+	// attempts base.Dial launches across the interleaved address
+	// list. Defaults to DefaultHappyEyeballsDelay.
+	HappyEyeballsDelay time.Duration
+
+	// ResolutionDelay is how long base.Dial is willing to wait for
+	// the second address family to resolve once the first one has.
+	// Defaults to DefaultResolutionDelay.
+	ResolutionDelay time.Duration
+
+	// TLSProfile configures the tls.Config base builds for a given
+	// host. The zero value keeps Go's defaults.
+	TLSProfile TLSProfile
 }
 
 func (o *Opts) GetContext() context.Context {
@@ -44,3 +76,19 @@ func (o *Opts) GetCleanupDNSEvery() time.Duration {
 func (o *Opts) GetTLSSkipVerify() bool {
 	return o.TLSSkipVerify
 }
+
+func (o *Opts) GetHappyEyeballsDelay() time.Duration {
+	if o.HappyEyeballsDelay == 0 {
+		return DefaultHappyEyeballsDelay
+	}
+
+	return o.HappyEyeballsDelay
+}
+
+func (o *Opts) GetResolutionDelay() time.Duration {
+	if o.ResolutionDelay == 0 {
+		return DefaultResolutionDelay
+	}
+
+	return o.ResolutionDelay
+}
@@ -0,0 +1,125 @@
+package dialers
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/9seconds/httransform/v2/cache"
+)
+
+// Resolver resolves a hostname to its IPv6 and IPv4 addresses
+// separately, each with the TTL that should be applied to the
+// per-entry cache expiry of the caller. Splitting by family lets
+// base.Dial start its Happy Eyeballs dial attempts as soon as one
+// family is ready instead of blocking on both. It lets dnsCache be
+// backed by something other than the host OS's resolver, e.g. DoH or
+// DoT.
+type Resolver interface {
+	LookupIPv6(ctx context.Context, host string) ([]string, time.Duration, error)
+	LookupIPv4(ctx context.Context, host string) ([]string, time.Duration, error)
+}
+
+// systemResolver is the default Resolver, delegating to whatever the
+// host OS is configured with. It does not know per-record TTLs, so it
+// always reports DNSCacheTTL.
+type systemResolver struct{}
+
+func (systemResolver) lookup(ctx context.Context, network, host string) ([]string, time.Duration, error) {
+	addrs, err := net.DefaultResolver.LookupIP(ctx, network, host)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ips := make([]string, len(addrs))
+	for i := range addrs {
+		ips[i] = addrs[i].String()
+	}
+
+	return ips, DNSCacheTTL, nil
+}
+
+func (s systemResolver) LookupIPv6(ctx context.Context, host string) ([]string, time.Duration, error) {
+	return s.lookup(ctx, "ip6", host)
+}
+
+func (s systemResolver) LookupIPv4(ctx context.Context, host string) ([]string, time.Duration, error) {
+	return s.lookup(ctx, "ip4", host)
+}
+
+type dnsCacheEntry struct {
+	ips       []string
+	expiresAt time.Time
+}
+
+type dnsCache struct {
+	cache    cache.Interface
+	resolver Resolver
+}
+
+func (d *dnsCache) getResolver() Resolver {
+	if d.resolver != nil {
+		return d.resolver
+	}
+
+	return systemResolver{}
+}
+
+func (d *dnsCache) lookup(ctx context.Context, host string, v6 bool) ([]string, error) {
+	key := dnsCacheKey(host, v6)
+
+	if item := d.cache.Get(key); item != nil {
+		entry := item.(*dnsCacheEntry)
+
+		if time.Now().Before(entry.expiresAt) {
+			return entry.ips, nil
+		}
+	}
+
+	lookupFn := d.getResolver().LookupIPv4
+	if v6 {
+		lookupFn = d.getResolver().LookupIPv6
+	}
+
+	ips, ttl, err := lookupFn(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	// A zero ttl is only treated as "unset" when there were no ips to
+	// have derived it from in the first place (e.g. resolveWithCNAMEChain
+	// on an empty answer section). When ips is non-empty, a
+	// wire Resolver has already reported the authoritative answer's
+	// real TTL via resolveWithCNAMEChain, and 0 there is a legitimate
+	// "do not cache this" from the server that must be honored, not
+	// clamped up to DNSCacheTTL.
+	if ttl <= 0 && len(ips) == 0 {
+		ttl = DNSCacheTTL
+	}
+
+	d.cache.Add(key, &dnsCacheEntry{
+		ips:       ips,
+		expiresAt: time.Now().Add(ttl),
+	})
+
+	return ips, nil
+}
+
+// LookupIPv6 and LookupIPv4 are cached wrappers around the configured
+// Resolver, used by base.Dial to run its Happy Eyeballs resolution
+// race.
+func (d *dnsCache) LookupIPv6(ctx context.Context, host string) ([]string, error) {
+	return d.lookup(ctx, host, true)
+}
+
+func (d *dnsCache) LookupIPv4(ctx context.Context, host string) ([]string, error) {
+	return d.lookup(ctx, host, false)
+}
+
+func dnsCacheKey(host string, v6 bool) string {
+	if v6 {
+		return host + "|6"
+	}
+
+	return host + "|4"
+}
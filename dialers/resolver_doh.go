@@ -0,0 +1,88 @@
+package dialers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DoHContentType is the media type RFC 8484 mandates for both the
+// request body and the response body of a DNS-over-HTTPS POST.
+const DoHContentType = "application/dns-message"
+
+// DoHResolver resolves hostnames using DNS-over-HTTPS (RFC 8484),
+// sending `application/dns-message` wire-format queries as an HTTP
+// POST to Endpoint.
+type DoHResolver struct {
+	// Endpoint is the DoH query URL, e.g.
+	// "https://dns.google/dns-query".
+	Endpoint string
+
+	// Client is used to perform the POST requests. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+}
+
+func (r *DoHResolver) client() *http.Client {
+	if r.Client != nil {
+		return r.Client
+	}
+
+	return http.DefaultClient
+}
+
+func (r *DoHResolver) query(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.RecursionDesired = true
+
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("cannot pack DoH query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.Endpoint, bytes.NewReader(packed))
+	if err != nil {
+		return nil, fmt.Errorf("cannot build DoH request: %w", err)
+	}
+
+	req.Header.Set("content-type", DoHContentType)
+	req.Header.Set("accept", DoHContentType)
+
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot perform DoH request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read DoH response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH endpoint returned status %d", resp.StatusCode)
+	}
+
+	answer := new(dns.Msg)
+	if err := answer.Unpack(body); err != nil {
+		return nil, fmt.Errorf("cannot unpack DoH response: %w", err)
+	}
+
+	return answer, nil
+}
+
+// LookupIPv6 implements Resolver, following CNAME chains as needed.
+func (r *DoHResolver) LookupIPv6(ctx context.Context, host string) ([]string, time.Duration, error) {
+	return resolveWithCNAMEChain(ctx, host, dns.TypeAAAA, r.query)
+}
+
+// LookupIPv4 implements Resolver, following CNAME chains as needed.
+func (r *DoHResolver) LookupIPv4(ctx context.Context, host string) ([]string, time.Duration, error) {
+	return resolveWithCNAMEChain(ctx, host, dns.TypeA, r.query)
+}
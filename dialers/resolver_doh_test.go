@@ -0,0 +1,141 @@
+package dialers
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDoHResolverQuerySendsWireFormatPOST(t *testing.T) {
+	var gotMethod, gotContentType string
+	var gotQuestion dns.Question
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotContentType = r.Header.Get("content-type")
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("cannot read request body: %v", err)
+			return
+		}
+
+		req := new(dns.Msg)
+		if err := req.Unpack(body); err != nil {
+			t.Errorf("cannot unpack request body: %v", err)
+			return
+		}
+		gotQuestion = req.Question[0]
+
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		resp.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+			A:   net.ParseIP("192.0.2.1"),
+		}}
+
+		packed, err := resp.Pack()
+		if err != nil {
+			t.Errorf("cannot pack response: %v", err)
+			return
+		}
+
+		w.Header().Set("content-type", DoHContentType)
+		w.Write(packed) // nolint: errcheck
+	}))
+	defer server.Close()
+
+	r := &DoHResolver{Endpoint: server.URL}
+
+	msg, err := r.query(context.Background(), "example.com", dns.TypeA)
+	if err != nil {
+		t.Fatalf("query returned an error: %v", err)
+	}
+
+	if gotMethod != http.MethodPost {
+		t.Fatalf("got method %q, want POST", gotMethod)
+	}
+
+	if gotContentType != DoHContentType {
+		t.Fatalf("got content-type %q, want %q", gotContentType, DoHContentType)
+	}
+
+	if gotQuestion.Name != dns.Fqdn("example.com") || gotQuestion.Qtype != dns.TypeA {
+		t.Fatalf("unexpected question: %+v", gotQuestion)
+	}
+
+	if len(msg.Answer) != 1 {
+		t.Fatalf("expected 1 answer, got %d", len(msg.Answer))
+	}
+
+	a, ok := msg.Answer[0].(*dns.A)
+	if !ok || a.A.String() != "192.0.2.1" {
+		t.Fatalf("unexpected answer: %+v", msg.Answer[0])
+	}
+}
+
+func TestDoHResolverQueryNon200StatusIsAnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	r := &DoHResolver{Endpoint: server.URL}
+
+	if _, err := r.query(context.Background(), "example.com", dns.TypeA); err == nil {
+		t.Fatal("expected an error for a non-200 response")
+	}
+}
+
+func TestDoHResolverLookupIPv4EndToEnd(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("cannot read request body: %v", err)
+			return
+		}
+
+		req := new(dns.Msg)
+		if err := req.Unpack(body); err != nil {
+			t.Errorf("cannot unpack request body: %v", err)
+			return
+		}
+
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		resp.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("192.0.2.1"),
+		}}
+
+		packed, err := resp.Pack()
+		if err != nil {
+			t.Errorf("cannot pack response: %v", err)
+			return
+		}
+
+		w.Header().Set("content-type", DoHContentType)
+		w.Write(packed) // nolint: errcheck
+	}))
+	defer server.Close()
+
+	r := &DoHResolver{Endpoint: server.URL}
+
+	ips, ttl, err := r.LookupIPv4(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("LookupIPv4 returned an error: %v", err)
+	}
+
+	if len(ips) != 1 || ips[0] != "192.0.2.1" {
+		t.Fatalf("unexpected ips: %v", ips)
+	}
+
+	if ttl.Seconds() != 60 {
+		t.Fatalf("unexpected ttl: %v", ttl)
+	}
+}
@@ -0,0 +1,133 @@
+package dialers
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// DoTDefaultPort is the well-known port for DNS-over-TLS, per
+	// RFC 7858.
+	DoTDefaultPort = "853"
+)
+
+// DoTResolver resolves hostnames using DNS-over-TLS (RFC 7858): a
+// regular DNS message, over TLS, using the same 2-byte length prefix
+// as classic DNS-over-TCP.
+type DoTResolver struct {
+	// Addr is the "host:port" of the DoT server. If the port is
+	// omitted, DoTDefaultPort is used.
+	Addr string
+
+	// TLSConfig is used for the handshake. If nil, a zero-value
+	// tls.Config is used (ServerName is derived from Addr).
+	TLSConfig *tls.Config
+
+	// Dialer performs the underlying TCP connection. If nil,
+	// net.Dialer{} is used.
+	Dialer *net.Dialer
+}
+
+func (r *DoTResolver) addr() string {
+	if _, _, err := net.SplitHostPort(r.Addr); err == nil {
+		return r.Addr
+	}
+
+	return net.JoinHostPort(r.Addr, DoTDefaultPort)
+}
+
+func (r *DoTResolver) dial(ctx context.Context) (*tls.Conn, error) {
+	dialer := r.Dialer
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+
+	addr := r.addr()
+
+	conf := r.TLSConfig
+	if conf == nil {
+		conf = &tls.Config{} // nolint: gosec
+	}
+
+	if conf.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err == nil {
+			conf = conf.Clone()
+			conf.ServerName = host
+		}
+	}
+
+	tlsDialer := tls.Dialer{NetDialer: dialer, Config: conf}
+
+	conn, err := tlsDialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot dial DoT server %s: %w", addr, err)
+	}
+
+	return conn.(*tls.Conn), nil
+}
+
+func (r *DoTResolver) query(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	conn, err := r.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.RecursionDesired = true
+
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("cannot pack DoT query: %w", err)
+	}
+
+	var length [2]byte
+	binary.BigEndian.PutUint16(length[:], uint16(len(packed)))
+
+	if _, err := conn.Write(length[:]); err != nil {
+		return nil, fmt.Errorf("cannot write DoT length prefix: %w", err)
+	}
+
+	if _, err := conn.Write(packed); err != nil {
+		return nil, fmt.Errorf("cannot write DoT query: %w", err)
+	}
+
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, fmt.Errorf("cannot read DoT length prefix: %w", err)
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return nil, fmt.Errorf("cannot read DoT response: %w", err)
+	}
+
+	answer := new(dns.Msg)
+	if err := answer.Unpack(body); err != nil {
+		return nil, fmt.Errorf("cannot unpack DoT response: %w", err)
+	}
+
+	return answer, nil
+}
+
+// LookupIPv6 implements Resolver, following CNAME chains as needed.
+func (r *DoTResolver) LookupIPv6(ctx context.Context, host string) ([]string, time.Duration, error) {
+	return resolveWithCNAMEChain(ctx, host, dns.TypeAAAA, r.query)
+}
+
+// LookupIPv4 implements Resolver, following CNAME chains as needed.
+func (r *DoTResolver) LookupIPv4(ctx context.Context, host string) ([]string, time.Duration, error) {
+	return resolveWithCNAMEChain(ctx, host, dns.TypeA, r.query)
+}
@@ -0,0 +1,70 @@
+package dialers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// wireMaxCNAMEChain bounds how many CNAME hops a dnsQueryFunc-based
+// lookup is willing to follow before giving up.
+const wireMaxCNAMEChain = 8
+
+// dnsQueryFunc performs a single DNS query over whatever transport a
+// Resolver uses and returns the raw answer message.
+type dnsQueryFunc func(ctx context.Context, name string, qtype uint16) (*dns.Msg, error)
+
+// resolveWithCNAMEChain queries qtype records for host using query,
+// following CNAME answers until an address record is found. It is
+// shared by every Resolver whose transport is plain DNS wire format
+// (DoH, DoT).
+func resolveWithCNAMEChain(ctx context.Context, host string, qtype uint16, query dnsQueryFunc) ([]string, time.Duration, error) {
+	var (
+		ttl       time.Duration
+		sawRecord bool
+	)
+
+	name := host
+
+	for hop := 0; hop < wireMaxCNAMEChain; hop++ {
+		msg, err := query(ctx, name, qtype)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var ips []string
+		cname := ""
+
+		for _, rr := range msg.Answer {
+			recordTTL := time.Duration(rr.Header().Ttl) * time.Second
+			if !sawRecord || recordTTL < ttl {
+				ttl = recordTTL
+			}
+
+			sawRecord = true
+
+			switch record := rr.(type) {
+			case *dns.A:
+				ips = append(ips, record.A.String())
+			case *dns.AAAA:
+				ips = append(ips, record.AAAA.String())
+			case *dns.CNAME:
+				cname = record.Target
+			}
+		}
+
+		if len(ips) > 0 {
+			return ips, ttl, nil
+		}
+
+		if cname == "" {
+			return nil, ttl, nil
+		}
+
+		name = cname
+	}
+
+	return nil, 0, fmt.Errorf("CNAME chain for %s is too long", host)
+}
@@ -0,0 +1,150 @@
+package dialers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func aRecord(name string, ip string, ttl uint32) dns.RR {
+	return &dns.A{
+		Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Ttl: ttl},
+		A:   net.ParseIP(ip),
+	}
+}
+
+func cnameRecord(name, target string, ttl uint32) dns.RR {
+	return &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: name, Rrtype: dns.TypeCNAME, Ttl: ttl},
+		Target: target,
+	}
+}
+
+func TestResolveWithCNAMEChainDirectAnswer(t *testing.T) {
+	query := func(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+		if name != "example.com" {
+			t.Fatalf("unexpected query for %q", name)
+		}
+
+		msg := &dns.Msg{}
+		msg.Answer = []dns.RR{aRecord(name, "192.0.2.1", 300)}
+
+		return msg, nil
+	}
+
+	ips, ttl, err := resolveWithCNAMEChain(context.Background(), "example.com", dns.TypeA, query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(ips) != 1 || ips[0] != "192.0.2.1" {
+		t.Fatalf("unexpected ips: %v", ips)
+	}
+
+	if ttl != 300*time.Second {
+		t.Fatalf("unexpected ttl: %v", ttl)
+	}
+}
+
+func TestResolveWithCNAMEChainZeroTTLRecordWinsMinimum(t *testing.T) {
+	query := func(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+		msg := &dns.Msg{}
+		msg.Answer = []dns.RR{
+			aRecord(name, "192.0.2.1", 0),
+			aRecord(name, "192.0.2.2", 300),
+		}
+
+		return msg, nil
+	}
+
+	_, ttl, err := resolveWithCNAMEChain(context.Background(), "example.com", dns.TypeA, query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ttl != 0 {
+		t.Fatalf("expected the legitimate 0 TTL to win the minimum, got %v", ttl)
+	}
+}
+
+func TestResolveWithCNAMEChainFollowsChain(t *testing.T) {
+	calls := 0
+	query := func(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+		calls++
+		msg := &dns.Msg{}
+
+		switch name {
+		case "example.com":
+			msg.Answer = []dns.RR{cnameRecord(name, "alias.example.com", 600)}
+		case "alias.example.com":
+			msg.Answer = []dns.RR{aRecord(name, "192.0.2.1", 60)}
+		default:
+			t.Fatalf("unexpected query for %q", name)
+		}
+
+		return msg, nil
+	}
+
+	ips, ttl, err := resolveWithCNAMEChain(context.Background(), "example.com", dns.TypeA, query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected 2 queries, got %d", calls)
+	}
+
+	if len(ips) != 1 || ips[0] != "192.0.2.1" {
+		t.Fatalf("unexpected ips: %v", ips)
+	}
+
+	// The minimum TTL across the chain should win.
+	if ttl != 60*time.Second {
+		t.Fatalf("unexpected ttl: %v", ttl)
+	}
+}
+
+func TestResolveWithCNAMEChainNoAnswer(t *testing.T) {
+	query := func(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+		return &dns.Msg{}, nil
+	}
+
+	ips, _, err := resolveWithCNAMEChain(context.Background(), "example.com", dns.TypeA, query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ips != nil {
+		t.Fatalf("expected no ips, got %v", ips)
+	}
+}
+
+func TestResolveWithCNAMEChainTooLong(t *testing.T) {
+	query := func(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+		msg := &dns.Msg{}
+		msg.Answer = []dns.RR{cnameRecord(name, "next."+name, 60)}
+
+		return msg, nil
+	}
+
+	_, _, err := resolveWithCNAMEChain(context.Background(), "example.com", dns.TypeA, query)
+	if err == nil {
+		t.Fatal("expected an error for a too-long CNAME chain")
+	}
+}
+
+func TestResolveWithCNAMEChainQueryError(t *testing.T) {
+	wantErr := errors.New("boom")
+	query := func(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+		return nil, wantErr
+	}
+
+	_, _, err := resolveWithCNAMEChain(context.Background(), "example.com", dns.TypeA, query)
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+}
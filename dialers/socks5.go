@@ -0,0 +1,188 @@
+package dialers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// SOCKS5 wire constants, per RFC 1928 and RFC 1929.
+const (
+	socks5Version = 0x05
+
+	socks5MethodNoAuth       = 0x00
+	socks5MethodUserPass     = 0x02
+	socks5MethodNoAcceptable = 0xFF
+
+	socks5CmdConnect = 0x01
+
+	socks5AddrIPv4   = 0x01
+	socks5AddrDomain = 0x03
+	socks5AddrIPv6   = 0x04
+
+	socks5ReplySucceeded = 0x00
+
+	socks5AuthVersion  = 0x01
+	socks5AuthSucceeds = 0x00
+
+	socks5MaxDomainLen = 255
+
+	// socks5MaxAuthFieldLen is the largest ULEN/PLEN RFC 1929 can
+	// encode: both are one-byte lengths.
+	socks5MaxAuthFieldLen = 255
+)
+
+func (c *chained) connectSOCKS5(ctx context.Context, conn net.Conn, host, port string) error {
+	methods := []byte{socks5MethodNoAuth}
+	if c.upstream.User != nil {
+		methods = []byte{socks5MethodUserPass}
+	}
+
+	greeting := append([]byte{socks5Version, byte(len(methods))}, methods...)
+
+	if _, err := conn.Write(greeting); err != nil {
+		return fmt.Errorf("cannot write SOCKS5 greeting: %w", err)
+	}
+
+	selection := make([]byte, 2)
+	if _, err := io.ReadFull(conn, selection); err != nil {
+		return fmt.Errorf("cannot read SOCKS5 method selection: %w", err)
+	}
+
+	if selection[0] != socks5Version {
+		return fmt.Errorf("unexpected SOCKS5 version %d", selection[0])
+	}
+
+	switch selection[1] {
+	case socks5MethodNoAuth:
+	case socks5MethodUserPass:
+		if err := c.socks5Authenticate(conn); err != nil {
+			return err
+		}
+	case socks5MethodNoAcceptable:
+		return fmt.Errorf("upstream proxy accepted none of our SOCKS5 auth methods")
+	default:
+		return fmt.Errorf("upstream proxy selected unsupported SOCKS5 method %d", selection[1])
+	}
+
+	target := host
+	if c.upstream.Scheme == "socks5" {
+		resolved, err := resolveHappyEyeballs(ctx, &c.dns, c.resolutionDelay, host)
+		if err != nil {
+			return fmt.Errorf("cannot resolve %s for SOCKS5: %w", host, err)
+		}
+
+		if len(resolved) == 0 {
+			return ErrNoIPs
+		}
+
+		target = resolved[0]
+	}
+
+	return socks5Connect(conn, target, port)
+}
+
+func (c *chained) socks5Authenticate(conn net.Conn) error {
+	user := c.upstream.User.Username()
+	password, _ := c.upstream.User.Password()
+
+	if len(user) > socks5MaxAuthFieldLen {
+		return fmt.Errorf("SOCKS5 username is too long (%d bytes)", len(user))
+	}
+
+	if len(password) > socks5MaxAuthFieldLen {
+		return fmt.Errorf("SOCKS5 password is too long (%d bytes)", len(password))
+	}
+
+	req := make([]byte, 0, 3+len(user)+len(password))
+	req = append(req, socks5AuthVersion, byte(len(user)))
+	req = append(req, user...)
+	req = append(req, byte(len(password)))
+	req = append(req, password...)
+
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("cannot write SOCKS5 auth request: %w", err)
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("cannot read SOCKS5 auth reply: %w", err)
+	}
+
+	if reply[1] != socks5AuthSucceeds {
+		return fmt.Errorf("SOCKS5 authentication rejected")
+	}
+
+	return nil
+}
+
+func socks5Connect(conn net.Conn, host, port string) error {
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid SOCKS5 target port %q: %w", port, err)
+	}
+
+	req := []byte{socks5Version, socks5CmdConnect, 0x00}
+	ip := net.ParseIP(host)
+
+	switch {
+	case ip == nil:
+		if len(host) > socks5MaxDomainLen {
+			return fmt.Errorf("hostname %q is too long for a SOCKS5 domain address", host)
+		}
+
+		req = append(req, socks5AddrDomain, byte(len(host)))
+		req = append(req, host...)
+	case ip.To4() != nil:
+		req = append(req, socks5AddrIPv4)
+		req = append(req, ip.To4()...)
+	default:
+		req = append(req, socks5AddrIPv6)
+		req = append(req, ip.To16()...)
+	}
+
+	req = append(req, byte(portNum>>8), byte(portNum))
+
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("cannot write SOCKS5 connect request: %w", err)
+	}
+
+	return socks5ReadReply(conn)
+}
+
+func socks5ReadReply(conn net.Conn) error {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("cannot read SOCKS5 connect reply: %w", err)
+	}
+
+	if header[1] != socks5ReplySucceeded {
+		return fmt.Errorf("SOCKS5 connect request failed with code %d", header[1])
+	}
+
+	var addrLen int
+
+	switch header[3] {
+	case socks5AddrIPv4:
+		addrLen = net.IPv4len
+	case socks5AddrIPv6:
+		addrLen = net.IPv6len
+	case socks5AddrDomain:
+		domainLen := make([]byte, 1)
+		if _, err := io.ReadFull(conn, domainLen); err != nil {
+			return fmt.Errorf("cannot read SOCKS5 bound address length: %w", err)
+		}
+
+		addrLen = int(domainLen[0])
+	default:
+		return fmt.Errorf("unknown SOCKS5 bound address type %d", header[3])
+	}
+
+	if _, err := io.ReadFull(conn, make([]byte, addrLen+2)); err != nil {
+		return fmt.Errorf("cannot read SOCKS5 bound address: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package dialers
+
+import (
+	"crypto/tls"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+)
+
+// TLSProfile describes the TLS parameters base should use when
+// connecting to a given host. The zero value keeps Go's defaults for
+// every field except ServerName, which base always derives from the
+// dialed host unless ServerNameOverride says otherwise.
+type TLSProfile struct {
+	MinVersion       uint16
+	MaxVersion       uint16
+	CipherSuites     []uint16
+	CurvePreferences []tls.CurveID
+	NextProtos       []string
+
+	// ServerNameOverride, if set, computes the SNI/certificate
+	// verification hostname to use for a given dialed host instead
+	// of the host itself.
+	ServerNameOverride func(host string) string
+}
+
+func (p *TLSProfile) serverName(host string) string {
+	if p.ServerNameOverride != nil {
+		return p.ServerNameOverride(host)
+	}
+
+	return host
+}
+
+// fingerprint identifies the fields of p which affect the resulting
+// tls.Config, so that two TLSProfiles which differ do not share a
+// cached *tls.Config for the same host. ServerNameOverride is a
+// function and cannot be compared, but its effect on a given host is
+// already folded into the cache key by getTLSConfig.
+func (p *TLSProfile) fingerprint() string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d|%d|%v|%v|%v", p.MinVersion, p.MaxVersion, p.CipherSuites, p.CurvePreferences, p.NextProtos)
+
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// CipherInfo describes a single cipher suite the running Go runtime
+// supports, as reported by ListCipherSuites.
+type CipherInfo struct {
+	Name     string
+	ID       uint16
+	Versions []uint16
+	Insecure bool
+}
+
+// ListCipherSuites enumerates every cipher suite the Go TLS stack
+// knows about, secure and insecure alike, so operators configuring
+// TLSProfile.CipherSuites can discover valid values instead of
+// guessing IDs.
+func ListCipherSuites() []CipherInfo {
+	suites := tls.CipherSuites()
+	insecure := tls.InsecureCipherSuites()
+
+	out := make([]CipherInfo, 0, len(suites)+len(insecure))
+
+	for _, s := range suites {
+		out = append(out, CipherInfo{
+			Name:     s.Name,
+			ID:       s.ID,
+			Versions: s.SupportedVersions,
+		})
+	}
+
+	for _, s := range insecure {
+		out = append(out, CipherInfo{
+			Name:     s.Name,
+			ID:       s.ID,
+			Versions: s.SupportedVersions,
+			Insecure: true,
+		})
+	}
+
+	return out
+}
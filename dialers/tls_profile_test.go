@@ -0,0 +1,58 @@
+package dialers
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestTLSProfileFingerprintStableForEqualProfiles(t *testing.T) {
+	a := TLSProfile{
+		MinVersion:       tls.VersionTLS12,
+		MaxVersion:       tls.VersionTLS13,
+		CipherSuites:     []uint16{tls.TLS_AES_128_GCM_SHA256},
+		CurvePreferences: []tls.CurveID{tls.X25519},
+		NextProtos:       []string{"h2"},
+	}
+	b := a
+
+	if a.fingerprint() != b.fingerprint() {
+		t.Fatal("equal profiles produced different fingerprints")
+	}
+}
+
+func TestTLSProfileFingerprintDiffersOnEachField(t *testing.T) {
+	base := TLSProfile{
+		MinVersion:       tls.VersionTLS12,
+		MaxVersion:       tls.VersionTLS13,
+		CipherSuites:     []uint16{tls.TLS_AES_128_GCM_SHA256},
+		CurvePreferences: []tls.CurveID{tls.X25519},
+		NextProtos:       []string{"h2"},
+	}
+	baseFingerprint := base.fingerprint()
+
+	variants := []TLSProfile{
+		{MinVersion: tls.VersionTLS13, MaxVersion: base.MaxVersion, CipherSuites: base.CipherSuites, CurvePreferences: base.CurvePreferences, NextProtos: base.NextProtos},
+		{MinVersion: base.MinVersion, MaxVersion: tls.VersionTLS12, CipherSuites: base.CipherSuites, CurvePreferences: base.CurvePreferences, NextProtos: base.NextProtos},
+		{MinVersion: base.MinVersion, MaxVersion: base.MaxVersion, CipherSuites: []uint16{tls.TLS_AES_256_GCM_SHA384}, CurvePreferences: base.CurvePreferences, NextProtos: base.NextProtos},
+		{MinVersion: base.MinVersion, MaxVersion: base.MaxVersion, CipherSuites: base.CipherSuites, CurvePreferences: []tls.CurveID{tls.CurveP256}, NextProtos: base.NextProtos},
+		{MinVersion: base.MinVersion, MaxVersion: base.MaxVersion, CipherSuites: base.CipherSuites, CurvePreferences: base.CurvePreferences, NextProtos: []string{"http/1.1"}},
+	}
+
+	for i, v := range variants {
+		if v.fingerprint() == baseFingerprint {
+			t.Errorf("variant %d unexpectedly matched the base fingerprint", i)
+		}
+	}
+}
+
+func TestTLSProfileFingerprintIgnoresServerNameOverride(t *testing.T) {
+	a := TLSProfile{MinVersion: tls.VersionTLS12}
+	b := TLSProfile{
+		MinVersion:         tls.VersionTLS12,
+		ServerNameOverride: func(host string) string { return "override" },
+	}
+
+	if a.fingerprint() != b.fingerprint() {
+		t.Fatal("ServerNameOverride should not affect the fingerprint")
+	}
+}